@@ -0,0 +1,90 @@
+// Package certs generates the self-signed serving certificates the webhook
+// needs to bootstrap TLS without an external cert-manager.
+package certs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// KeyPair is a PEM-encoded certificate and its private key.
+type KeyPair struct {
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// GenerateServingCert creates a self-signed CA and a leaf certificate signed by
+// that CA, valid for dnsNames (typically the webhook Service's short and FQDN
+// forms). It returns the CA, to be published as the webhook's caBundle, and the
+// leaf keypair the server should present to callers.
+func GenerateServingCert(dnsNames []string) (ca KeyPair, leaf KeyPair, err error) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return ca, leaf, fmt.Errorf("could not generate CA key: %w", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kube-failover-webhook-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	caCertDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return ca, leaf, fmt.Errorf("could not self-sign CA certificate: %w", err)
+	}
+
+	caCert, err := x509.ParseCertificate(caCertDER)
+	if err != nil {
+		return ca, leaf, fmt.Errorf("could not parse CA certificate: %w", err)
+	}
+
+	ca = KeyPair{
+		CertPEM: pemEncode("CERTIFICATE", caCertDER),
+		KeyPEM:  pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(caKey)),
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return ca, leaf, fmt.Errorf("could not generate serving key: %w", err)
+	}
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	leafCertDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		return ca, leaf, fmt.Errorf("could not sign serving certificate: %w", err)
+	}
+
+	leaf = KeyPair{
+		CertPEM: pemEncode("CERTIFICATE", leafCertDER),
+		KeyPEM:  pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(leafKey)),
+	}
+
+	return ca, leaf, nil
+}
+
+func pemEncode(blockType string, der []byte) []byte {
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: blockType, Bytes: der})
+	return buf.Bytes()
+}
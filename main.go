@@ -8,35 +8,88 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
 
 	wh "kube-failover-webhook/webhook"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog"
 )
 
 func main() {
 	var port int
+	var metricsPort int
 	var certFile string
 	var keyFile string
+	var configFile string
+	var namespaces string
+	var serviceName string
+	var certNamespace string
+	var certSecretName string
+	var mutatingWebhookConfigName string
+	var validatingWebhookConfigName string
 
 	flag.IntVar(&port, "port", 8443, "kube-failover webhook server port")
+	flag.IntVar(&metricsPort, "metricsPort", 8080, "port to serve /metrics on")
 	flag.StringVar(&certFile, "tlsCertFile", "/etc/webhook/certs/cert.pem", "x509 Certificate file for TLS connection")
 	flag.StringVar(&keyFile, "tlsKeyFile", "/etc/webhook/certs/key.pem", "x509 Private key file for TLS connection")
+	flag.StringVar(&configFile, "config", "", "path to the toleration policy config file (YAML or JSON); defaults to the built-in virt-launcher policy")
+	flag.StringVar(&namespaces, "namespaces", "", "comma-separated list of namespaces to restrict mutation and validation to; defaults to all namespaces")
+	flag.StringVar(&serviceName, "serviceName", "kube-failover-webhook", "name of the Service fronting this webhook, used as the bootstrapped certificate's DNS name")
+	flag.StringVar(&certNamespace, "certNamespace", "kubevirt-system", "namespace to read/write the bootstrapped TLS Secret in")
+	flag.StringVar(&certSecretName, "certSecretName", "kube-failover-webhook-certs", "name of the Secret holding the bootstrapped TLS certificate")
+	flag.StringVar(&mutatingWebhookConfigName, "mutatingWebhookConfigName", "kube-failover-webhook-mutate", "name of the MutatingWebhookConfiguration to patch with the bootstrapped CA bundle")
+	flag.StringVar(&validatingWebhookConfigName, "validatingWebhookConfigName", "kube-failover-webhook-validate", "name of the ValidatingWebhookConfiguration to patch with the bootstrapped CA bundle")
 	flag.Parse()
 
-	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err := wh.LoadPolicy(configFile); err != nil {
+		klog.Fatalf("Failed to load toleration policy: %s", err)
+	}
+
+	if namespaces != "" {
+		wh.AllowedNamespaces = strings.Split(namespaces, ",")
+	}
+
+	source := &certSource{
+		certFile:                    certFile,
+		keyFile:                     keyFile,
+		namespace:                   certNamespace,
+		secretName:                  certSecretName,
+		mutatingWebhookConfigName:   mutatingWebhookConfigName,
+		validatingWebhookConfigName: validatingWebhookConfigName,
+		serviceName:                 serviceName,
+	}
+
+	keyPair, err := source.load()
 	if err != nil {
-		klog.Errorf("Failed to load key pair: %s", err)
+		klog.Fatalf("Failed to load TLS serving certificate: %s", err)
 	}
 
+	var currentCert atomic.Value
+	currentCert.Store(&keyPair)
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/mutate", wh.HandleMutate)
+	mux.HandleFunc("/validate", wh.HandleValidate)
 
 	webhookServer := &http.Server{
-		Addr:      fmt.Sprintf(":%d", port),
-		Handler:   mux,
-		TLSConfig: &tls.Config{Certificates: []tls.Certificate{keyPair}},
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return currentCert.Load().(*tls.Certificate), nil
+			},
+		},
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", metricsPort),
+		Handler: metricsMux,
 	}
 
 	klog.Info("Starting kube-failover webhook server...")
@@ -47,10 +100,31 @@ func main() {
 		}
 	}()
 
+	go func() {
+		klog.Infof("Serving metrics on :%d/metrics", metricsPort)
+		if err := metricsServer.ListenAndServe(); err != nil {
+			klog.Errorf("Failed to listen and serve metrics server: %s", err)
+		}
+	}()
+
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			klog.Info("SIGHUP received, reloading TLS serving certificate...")
+			if reloaded, err := source.load(); err != nil {
+				klog.Errorf("Could not reload TLS serving certificate: %s", err)
+			} else {
+				currentCert.Store(&reloaded)
+				klog.Info("TLS serving certificate reloaded")
+			}
+			continue
+		}
 
-	klog.Info("OS shutdown signal received...")
-	webhookServer.Shutdown(context.Background())
+		klog.Info("OS shutdown signal received...")
+		webhookServer.Shutdown(context.Background())
+		metricsServer.Shutdown(context.Background())
+		return
+	}
 }
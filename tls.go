@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+
+	"kube-failover-webhook/certs"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+// certSource knows how to (re)load the webhook's serving certificate, either
+// from tlsCertFile/tlsKeyFile on disk or, when those are absent, by
+// bootstrapping a self-signed one via ensureServingCert. SIGHUP reloads use
+// the same source the initial load used.
+type certSource struct {
+	certFile string
+	keyFile  string
+
+	namespace                   string
+	secretName                  string
+	mutatingWebhookConfigName   string
+	validatingWebhookConfigName string
+	serviceName                 string
+
+	clientset kubernetes.Interface
+}
+
+// load returns the keypair the server should present, bootstrapping one if
+// certFile/keyFile can't be read.
+func (s *certSource) load() (tls.Certificate, error) {
+	if keyPair, err := tls.LoadX509KeyPair(s.certFile, s.keyFile); err == nil {
+		return keyPair, nil
+	}
+
+	klog.Info("No readable TLS keypair on disk; bootstrapping a self-signed serving certificate")
+
+	client, err := s.client()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM, keyPEM, err := ensureServingCert(context.Background(), client, s.namespace, s.secretName, s.mutatingWebhookConfigName, s.validatingWebhookConfigName, s.serviceName)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not bootstrap TLS serving certificate: %w", err)
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func (s *certSource) client() (kubernetes.Interface, error) {
+	if s.clientset != nil {
+		return s.clientset, nil
+	}
+
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not build in-cluster client config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not build Kubernetes client: %w", err)
+	}
+
+	s.clientset = clientset
+	return clientset, nil
+}
+
+// ensureServingCert returns the PEM-encoded cert/key the webhook server should
+// present. If namespace/secretName already holds one it is reused; otherwise a
+// fresh CA + leaf keypair is generated and stored in the secret. Either way,
+// the secret's CA is (re)patched into both mutatingWebhookConfigName's and
+// validatingWebhookConfigName's caBundle, so a manifest re-apply that resets
+// caBundle to "" while the secret persists gets corrected on the next restart
+// instead of leaving both webhooks silently untrusted.
+func ensureServingCert(ctx context.Context, client kubernetes.Interface, namespace, secretName, mutatingWebhookConfigName, validatingWebhookConfigName, serviceName string) ([]byte, []byte, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err == nil {
+		caPEM := secret.Data["ca.crt"]
+		if err := patchCABundles(ctx, client, mutatingWebhookConfigName, validatingWebhookConfigName, caPEM); err != nil {
+			return nil, nil, err
+		}
+
+		return secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, nil, fmt.Errorf("could not get secret %s/%s: %w", namespace, secretName, err)
+	}
+
+	dnsNames := []string{
+		serviceName,
+		fmt.Sprintf("%s.%s.svc", serviceName, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, namespace),
+	}
+
+	ca, leaf, err := certs.GenerateServingCert(dnsNames)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate serving certificate: %w", err)
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       leaf.CertPEM,
+			corev1.TLSPrivateKeyKey: leaf.KeyPEM,
+			"ca.crt":                ca.CertPEM,
+		},
+	}
+
+	if _, err := client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return nil, nil, fmt.Errorf("could not create secret %s/%s: %w", namespace, secretName, err)
+	}
+
+	if err := patchCABundles(ctx, client, mutatingWebhookConfigName, validatingWebhookConfigName, ca.CertPEM); err != nil {
+		return nil, nil, err
+	}
+
+	return leaf.CertPEM, leaf.KeyPEM, nil
+}
+
+func patchCABundles(ctx context.Context, client kubernetes.Interface, mutatingWebhookConfigName, validatingWebhookConfigName string, caPEM []byte) error {
+	if err := patchMutatingCABundle(ctx, client, mutatingWebhookConfigName, caPEM); err != nil {
+		return err
+	}
+
+	return patchValidatingCABundle(ctx, client, validatingWebhookConfigName, caPEM)
+}
+
+func caBundlePatch(caPEM []byte) []byte {
+	return []byte(fmt.Sprintf(
+		`[{"op":"replace","path":"/webhooks/0/clientConfig/caBundle","value":%q}]`,
+		base64.StdEncoding.EncodeToString(caPEM),
+	))
+}
+
+func patchMutatingCABundle(ctx context.Context, client kubernetes.Interface, webhookConfigName string, caPEM []byte) error {
+	_, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Patch(ctx, webhookConfigName, types.JSONPatchType, caBundlePatch(caPEM), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("could not patch MutatingWebhookConfiguration %s: %w", webhookConfigName, err)
+	}
+
+	klog.Infof("Patched caBundle on MutatingWebhookConfiguration %s", webhookConfigName)
+	return nil
+}
+
+func patchValidatingCABundle(ctx context.Context, client kubernetes.Interface, webhookConfigName string, caPEM []byte) error {
+	_, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Patch(ctx, webhookConfigName, types.JSONPatchType, caBundlePatch(caPEM), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("could not patch ValidatingWebhookConfiguration %s: %w", webhookConfigName, err)
+	}
+
+	klog.Infof("Patched caBundle on ValidatingWebhookConfiguration %s", webhookConfigName)
+	return nil
+}
@@ -0,0 +1,225 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog"
+)
+
+var (
+	runtimeScheme = runtime.NewScheme()
+	codecs        = serializer.NewCodecFactory(runtimeScheme)
+	deserializer  = codecs.UniversalDeserializer()
+
+	admissionV1GVK      = admissionv1.SchemeGroupVersion.WithKind("AdmissionReview")
+	admissionV1beta1GVK = v1beta1.SchemeGroupVersion.WithKind("AdmissionReview")
+)
+
+func init() {
+	utilruntime.Must(admissionv1.AddToScheme(runtimeScheme))
+	utilruntime.Must(v1beta1.AddToScheme(runtimeScheme))
+}
+
+// admissionResult is the version-agnostic outcome of a policy decision. serveAdmission
+// translates it into an admission/v1 or admission/v1beta1 AdmissionResponse depending on
+// which AdmissionReview version the API server sent.
+type admissionResult struct {
+	allowed bool
+	message string
+	patch   []byte
+}
+
+// admissionHandlerFunc decides the outcome for a single admitted pod.
+type admissionHandlerFunc func(pod corev1.Pod) admissionResult
+
+// serveAdmission decodes the incoming AdmissionReview in whichever of admission/v1 or
+// admission/v1beta1 the caller used, decodes the pod it carries, delegates to handle
+// for the policy decision, and writes back an AdmissionReview of the same version. It
+// is shared by the mutating and validating webhook endpoints so the HTTP/decoding/
+// response boilerplate and the v1/v1beta1 negotiation only live in one place.
+func serveAdmission(w http.ResponseWriter, r *http.Request, handle admissionHandlerFunc) {
+	var body []byte
+	if r.Body != nil {
+		if data, err := ioutil.ReadAll(r.Body); err == nil {
+			body = data
+		}
+	}
+
+	if len(body) == 0 {
+		klog.Error("Empty body")
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		klog.Errorf("Content-Type=%s, expect application/json", contentType)
+		http.Error(w, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	obj, gvk, err := deserializer.Decode(body, nil, nil)
+	if err != nil {
+		klog.Errorf("Can't decode body: %s", err)
+		decodeErrorsTotal.Inc()
+		http.Error(w, fmt.Sprintf("couldn't decode body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	var uid types.UID
+	var rawObject []byte
+
+	switch *gvk {
+	case admissionV1GVK:
+		ar, ok := obj.(*admissionv1.AdmissionReview)
+		if !ok || ar.Request == nil {
+			klog.Errorf("Unexpected admission/v1 payload")
+			http.Error(w, "unexpected admission/v1 payload", http.StatusBadRequest)
+			return
+		}
+		uid = ar.Request.UID
+		rawObject = ar.Request.Object.Raw
+	case admissionV1beta1GVK:
+		ar, ok := obj.(*v1beta1.AdmissionReview)
+		if !ok || ar.Request == nil {
+			klog.Errorf("Unexpected admission/v1beta1 payload")
+			http.Error(w, "unexpected admission/v1beta1 payload", http.StatusBadRequest)
+			return
+		}
+		uid = ar.Request.UID
+		rawObject = ar.Request.Object.Raw
+	default:
+		klog.Errorf("Unsupported AdmissionReview version: %s", gvk)
+		http.Error(w, fmt.Sprintf("unsupported AdmissionReview version: %s", gvk), http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+
+	var pod corev1.Pod
+	var result admissionResult
+	if err := json.Unmarshal(rawObject, &pod); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %s", err)
+		decodeErrorsTotal.Inc()
+		result = admissionResult{message: err.Error()}
+	} else {
+		result = handle(pod)
+	}
+
+	outcome := result.outcome()
+	admissionRequestsTotal.WithLabelValues(pod.Namespace, outcome).Inc()
+	handlerDuration.WithLabelValues(pod.Namespace, outcome).Observe(time.Since(start).Seconds())
+	if outcome == "patched" {
+		mutationsAppliedTotal.WithLabelValues(pod.Namespace).Inc()
+		patchBytes.Observe(float64(len(result.patch)))
+	}
+
+	logAdmission(uid, pod, result)
+
+	resp, err := marshalAdmissionReview(*gvk, uid, result)
+	if err != nil {
+		klog.Errorf("Couldn't encode response: %s", err)
+		http.Error(w, fmt.Sprintf("couldn't encode response: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := w.Write(resp); err != nil {
+		klog.Errorf("Couldn't write response: %s", err)
+		http.Error(w, fmt.Sprintf("couldn't write response: %s", err), http.StatusInternalServerError)
+	}
+}
+
+// admissionAudit is the structured, per-admission log record written in place
+// of the old one-line klog.Infof, so operators can tell whether the failover
+// mutator is actually firing on the pods they expect.
+type admissionAudit struct {
+	UID       string `json:"uid"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Outcome   string `json:"outcome"`
+	Mutated   bool   `json:"mutated"`
+	Patch     string `json:"patch,omitempty"`
+}
+
+func logAdmission(uid types.UID, pod corev1.Pod, result admissionResult) {
+	audit := admissionAudit{
+		UID:       string(uid),
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Outcome:   result.outcome(),
+		Mutated:   len(result.patch) > 0,
+		Patch:     string(result.patch),
+	}
+
+	line, err := json.Marshal(audit)
+	if err != nil {
+		klog.Errorf("Could not marshal admission audit log: %s", err)
+		return
+	}
+
+	klog.Info(string(line))
+}
+
+// marshalAdmissionReview builds an AdmissionReview of the given version carrying result
+// and marshals it. admission/v1 requires apiVersion/kind to be set explicitly on the
+// response, which admission/v1beta1 never needed.
+func marshalAdmissionReview(gvk schema.GroupVersionKind, uid types.UID, result admissionResult) ([]byte, error) {
+	switch gvk {
+	case admissionV1GVK:
+		return json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: admissionV1GVK.GroupVersion().String(),
+				Kind:       admissionV1GVK.Kind,
+			},
+			Response: &admissionv1.AdmissionResponse{
+				UID:     uid,
+				Allowed: result.allowed,
+				Result:  resultStatus(result.message),
+				Patch:   result.patch,
+				PatchType: func() *admissionv1.PatchType {
+					if len(result.patch) == 0 {
+						return nil
+					}
+					patchType := admissionv1.PatchTypeJSONPatch
+					return &patchType
+				}(),
+			},
+		})
+	default:
+		return json.Marshal(v1beta1.AdmissionReview{
+			Response: &v1beta1.AdmissionResponse{
+				UID:     uid,
+				Allowed: result.allowed,
+				Result:  resultStatus(result.message),
+				Patch:   result.patch,
+				PatchType: func() *v1beta1.PatchType {
+					if len(result.patch) == 0 {
+						return nil
+					}
+					patchType := v1beta1.PatchTypeJSONPatch
+					return &patchType
+				}(),
+			},
+		})
+	}
+}
+
+func resultStatus(message string) *metav1.Status {
+	if message == "" {
+		return nil
+	}
+	return &metav1.Status{Message: message}
+}
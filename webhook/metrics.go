@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	admissionRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_failover_webhook_admission_requests_total",
+		Help: "Total number of admission requests handled, by namespace and outcome (allowed, patched, errored).",
+	}, []string{"namespace", "outcome"})
+
+	mutationsAppliedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_failover_webhook_mutations_applied_total",
+		Help: "Total number of admission requests that resulted in a toleration patch, by namespace.",
+	}, []string{"namespace"})
+
+	decodeErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kube_failover_webhook_decode_errors_total",
+		Help: "Total number of admission requests whose AdmissionReview or pod body failed to decode.",
+	})
+
+	patchBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kube_failover_webhook_patch_bytes",
+		Help:    "Size in bytes of the JSON patch returned for mutated pods.",
+		Buckets: prometheus.ExponentialBuckets(16, 2, 8),
+	})
+
+	handlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kube_failover_webhook_handler_duration_seconds",
+		Help:    "Time spent handling an admission request, by namespace and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"namespace", "outcome"})
+)
+
+// outcome classifies r for metrics and audit logging.
+func (r admissionResult) outcome() string {
+	switch {
+	case r.message != "":
+		return "errored"
+	case len(r.patch) > 0:
+		return "patched"
+	default:
+		return "allowed"
+	}
+}
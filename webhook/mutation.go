@@ -2,31 +2,16 @@ package webhook
 
 import (
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net/http"
 
-	"k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/klog"
 )
 
+// These describe the built-in default policy (see defaultPolicy in policy.go),
+// used when the webhook is started without --config. See manifests/policy.sample.yaml
+// for the equivalent policy file.
 const (
-	/*
-		  tolerations:
-		  - effect: NoExecute
-		    key: node.kubernetes.io/not-ready
-		    operator: Exists
-		    tolerationSeconds: 300
-		  - effect: NoExecute
-		    key: node.kubernetes.io/unreachable
-		    operator: Exists
-			tolerationSeconds: 300
-	*/
-
 	virtLauncherLabelKey   string = "kubevirt.io"
 	virtLauncherLabelValue string = "virt-launcher"
 
@@ -34,17 +19,16 @@ const (
 	unreachableTolerationsKey string = "node.kubernetes.io/unreachable"
 
 	controllerNameSpaceName string = "kubevirt-system"
-)
 
-var (
-	runtimeScheme = runtime.NewScheme()
-	codecs        = serializer.NewCodecFactory(runtimeScheme)
-	deserializer  = codecs.UniversalDeserializer()
-
-	CustomNotReadyTolerationSeconds    int
-	CustomUnreachableTolerationSeconds int
+	// defaultTolerationSeconds is the tolerationSeconds the built-in default
+	// policy injects for not-ready/unreachable, matching manifests/policy.sample.yaml.
+	defaultTolerationSeconds int64 = 300
 )
 
+// AllowedNamespaces, when non-empty, restricts mutation and validation to pods
+// in the listed namespaces. An empty list scopes the webhook to every namespace.
+var AllowedNamespaces []string
+
 type patchOps struct {
 	// https://kubernetes.io/blog/2019/03/21/a-guide-to-kubernetes-admission-controllers/
 	Op    string      `json:"op"`
@@ -53,103 +37,25 @@ type patchOps struct {
 }
 
 func HandleMutate(w http.ResponseWriter, r *http.Request) {
-	var body []byte
-	if r.Body != nil {
-		if data, err := ioutil.ReadAll(r.Body); err == nil {
-			body = data
-		}
-	}
-
-	if len(body) == 0 {
-		klog.Error("Empty body")
-		http.Error(w, "empty body", http.StatusBadRequest)
-		return
-	}
-
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		klog.Errorf("Content-Type=%s, expect application/json", contentType)
-		http.Error(w, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
-		return
-	}
-
-	var admissionResponse *v1beta1.AdmissionResponse
-	ar := v1beta1.AdmissionReview{}
-	_, _, err := deserializer.Decode(body, nil, &ar)
-	if err != nil {
-		klog.Errorf("Can't decode body: %s", err)
-		admissionResponse = &v1beta1.AdmissionResponse{
-			Result: &metav1.Status{
-				Message: err.Error(),
-			},
-		}
-	} else {
-		admissionResponse = mutate(&ar)
-	}
-
-	admissionReview := v1beta1.AdmissionReview{}
-	if admissionResponse != nil {
-		admissionReview.Response = admissionResponse
-		if ar.Request != nil {
-			admissionReview.Response.UID = ar.Request.UID
-		}
-	}
-
-	resp, err := json.Marshal(admissionReview)
-	if err != nil {
-		klog.Errorf("Couldn't encode response: %s", err)
-		http.Error(w, fmt.Sprintf("couldn't encode response: %s", err), http.StatusInternalServerError)
-	}
-
-	klog.Infof("Writing response...")
-
-	_, err = w.Write(resp)
-	if err != nil {
-		klog.Errorf("Couldn't write response: %s", err)
-		http.Error(w, fmt.Sprintf("couldn't write response: %s", err), http.StatusInternalServerError)
-	}
+	serveAdmission(w, r, mutate)
 }
 
-func mutate(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
-	req := ar.Request
-
-	var pod corev1.Pod
-	err := json.Unmarshal(req.Object.Raw, &pod)
-	if err != nil {
-		klog.Errorf("Could not unmarshal raw object: %s", err)
-		return &v1beta1.AdmissionResponse{
-			Result: &metav1.Status{
-				Message: err.Error(),
-			},
-		}
+func mutate(pod corev1.Pod) admissionResult {
+	if !namespaceAllowed(pod) || !isVirtLauncherPod(pod) {
+		return admissionResult{allowed: true}
 	}
 
 	if !mutateRequired(pod) {
-		return &v1beta1.AdmissionResponse{
-			Allowed: true,
-		}
+		return admissionResult{allowed: true}
 	}
 
 	patchData, err := patchTolerations(pod)
-
 	if err != nil {
 		klog.Errorf("Could not make patch data: %s", err)
-		return &v1beta1.AdmissionResponse{
-			Result: &metav1.Status{
-				Message: err.Error(),
-			},
-		}
+		return admissionResult{message: err.Error()}
 	}
 
-	klog.Infof("AdmissionResponse: patch=%s", string(patchData))
-	return &v1beta1.AdmissionResponse{
-		Allowed: true,
-		Patch:   patchData,
-		PatchType: func() *v1beta1.PatchType {
-			patchType := v1beta1.PatchTypeJSONPatch
-			return &patchType
-		}(),
-	}
+	return admissionResult{allowed: true, patch: patchData}
 }
 
 func patchTolerations(pod corev1.Pod) ([]byte, error) {
@@ -162,12 +68,10 @@ func patchTolerations(pod corev1.Pod) ([]byte, error) {
 			Value: getDefaultTolerations(),
 		})
 	} else {
-		if !existsToleration(pod, notReadyTolerationsKey) {
-			pod.Spec.Tolerations = append(pod.Spec.Tolerations, getDefaultNotReadyTolerations())
-		}
-
-		if !existsToleration(pod, unreachableTolerationsKey) {
-			pod.Spec.Tolerations = append(pod.Spec.Tolerations, getDefaultUnreachableTolerations())
+		for _, tp := range getPolicy().Tolerations {
+			if !existsToleration(pod, tp.Key) {
+				pod.Spec.Tolerations = append(pod.Spec.Tolerations, tp.toCoreToleration())
+			}
 		}
 
 		patch = append(patch, patchOps{
@@ -180,55 +84,17 @@ func patchTolerations(pod corev1.Pod) ([]byte, error) {
 	return json.Marshal(patch)
 }
 
-func appendDefaultTolerations(pod corev1.Pod) corev1.Pod {
-	if pod.Spec.Tolerations == nil {
-		pod.Spec.Tolerations = getDefaultTolerations()
-	} else {
-		if !existsToleration(pod, notReadyTolerationsKey) {
-			pod.Spec.Tolerations = append(pod.Spec.Tolerations, getDefaultNotReadyTolerations())
-		}
-
-		if !existsToleration(pod, unreachableTolerationsKey) {
-			pod.Spec.Tolerations = append(pod.Spec.Tolerations, getDefaultUnreachableTolerations())
-		}
-	}
-
-	return pod
-}
-
+// getDefaultTolerations returns the tolerations the currently loaded policy
+// injects into a pod that has none yet.
 func getDefaultTolerations() []corev1.Toleration {
-	var defaultTolerations []corev1.Toleration
-
-	defaultTolerations = append(defaultTolerations, getDefaultNotReadyTolerations())
-	defaultTolerations = append(defaultTolerations, getDefaultUnreachableTolerations())
-
-	return defaultTolerations
-}
-
-func getDefaultNotReadyTolerations() corev1.Toleration {
-	var defaultNotReadyToleration corev1.Toleration
-
-	defaultNotReadyToleration.Key = notReadyTolerationsKey
-	defaultNotReadyToleration.Operator = corev1.TolerationOpExists
-	defaultNotReadyToleration.Effect = corev1.TaintEffectNoExecute
+	policyTolerations := getPolicy().Tolerations
+	tolerations := make([]corev1.Toleration, 0, len(policyTolerations))
 
-	temp := int64(CustomNotReadyTolerationSeconds)
-	defaultNotReadyToleration.TolerationSeconds = &temp
-
-	return defaultNotReadyToleration
-}
-
-func getDefaultUnreachableTolerations() corev1.Toleration {
-	var defaultUnreachableToleration corev1.Toleration
-
-	defaultUnreachableToleration.Key = notReadyTolerationsKey
-	defaultUnreachableToleration.Operator = corev1.TolerationOpExists
-	defaultUnreachableToleration.Effect = corev1.TaintEffectNoExecute
-
-	temp := int64(CustomUnreachableTolerationSeconds)
-	defaultUnreachableToleration.TolerationSeconds = &temp
+	for _, tp := range policyTolerations {
+		tolerations = append(tolerations, tp.toCoreToleration())
+	}
 
-	return defaultUnreachableToleration
+	return tolerations
 }
 
 func existsToleration(pod corev1.Pod, tolerationKey string) bool {
@@ -246,17 +112,31 @@ func existsToleration(pod corev1.Pod, tolerationKey string) bool {
 }
 
 func mutateRequired(pod corev1.Pod) bool {
-	if !existsToleration(pod, notReadyTolerationsKey) || !existsToleration(pod, unreachableTolerationsKey) {
-		return true
+	for _, tp := range getPolicy().Tolerations {
+		if !existsToleration(pod, tp.Key) {
+			return true
+		}
 	}
 
 	return false
 }
 
 func isVirtLauncherPod(pod corev1.Pod) bool {
-	if !existsToleration(pod, notReadyTolerationsKey) || !existsToleration(pod, unreachableTolerationsKey) {
+	return getPolicy().Selector.Matches(pod)
+}
+
+// namespaceAllowed reports whether pod's namespace is covered by the
+// cluster admin's --namespaces allow-list, or true if no allow-list is set.
+func namespaceAllowed(pod corev1.Pod) bool {
+	if len(AllowedNamespaces) == 0 {
 		return true
 	}
 
+	for _, ns := range AllowedNamespaces {
+		if ns == pod.Namespace {
+			return true
+		}
+	}
+
 	return false
 }
@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func secondsPtr(s int64) *int64 {
+	return &s
+}
+
+func TestIsVirtLauncherPod(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		want bool
+	}{
+		{
+			name: "virt-launcher pod",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "non-virt-launcher pod",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "nginx"},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "unlabeled pod",
+			pod:  corev1.Pod{},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isVirtLauncherPod(tt.pod); got != tt.want {
+				t.Errorf("isVirtLauncherPod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutateRequired(t *testing.T) {
+	virtLauncherPod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+	}
+
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		want bool
+	}{
+		{
+			name: "no tolerations",
+			pod:  virtLauncherPod,
+			want: true,
+		},
+		{
+			name: "partial tolerations",
+			pod: corev1.Pod{
+				ObjectMeta: virtLauncherPod.ObjectMeta,
+				Spec: corev1.PodSpec{
+					Tolerations: []corev1.Toleration{
+						{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "both tolerations present",
+			pod: corev1.Pod{
+				ObjectMeta: virtLauncherPod.ObjectMeta,
+				Spec: corev1.PodSpec{
+					Tolerations: []corev1.Toleration{
+						{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(300)},
+						{Key: unreachableTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(300)},
+					},
+				},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mutateRequired(tt.pod); got != tt.want {
+				t.Errorf("mutateRequired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutateSkipsNonVirtLauncherPods(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "nginx"},
+		},
+	}
+
+	result := mutate(pod)
+	if !result.allowed || len(result.patch) != 0 {
+		t.Errorf("mutate() of a non-virt-launcher pod = %+v, want allowed with no patch", result)
+	}
+}
+
+func TestMutateSkipsDisallowedNamespace(t *testing.T) {
+	AllowedNamespaces = []string{"kubevirt-system"}
+	defer func() { AllowedNamespaces = nil }()
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Labels:    map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+	}
+
+	result := mutate(pod)
+	if !result.allowed || len(result.patch) != 0 {
+		t.Errorf("mutate() outside the namespace allow-list = %+v, want allowed with no patch", result)
+	}
+}
+
+func TestMutateLeavesExistingTolerationSecondsUnchanged(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(5)},
+			},
+		},
+	}
+
+	result := mutate(pod)
+	if !result.allowed || len(result.patch) == 0 {
+		t.Fatalf("mutate() of a pod with a partial toleration = %+v, want allowed with a patch", result)
+	}
+
+	var patch []struct {
+		Op    string              `json:"op"`
+		Path  string              `json:"path"`
+		Value []corev1.Toleration `json:"value"`
+	}
+	if err := json.Unmarshal(result.patch, &patch); err != nil {
+		t.Fatalf("could not unmarshal patch %s: %s", result.patch, err)
+	}
+	if len(patch) != 1 || patch[0].Path != "/spec/tolerations" {
+		t.Fatalf("patch = %+v, want a single replace of /spec/tolerations", patch)
+	}
+
+	tolerations := patch[0].Value
+
+	var sawUnreachable bool
+	for _, toleration := range tolerations {
+		switch toleration.Key {
+		case notReadyTolerationsKey:
+			if toleration.TolerationSeconds == nil || *toleration.TolerationSeconds != 5 {
+				t.Errorf("mutate() changed the existing not-ready tolerationSeconds to %v, want unchanged at 5", toleration.TolerationSeconds)
+			}
+		case unreachableTolerationsKey:
+			sawUnreachable = true
+		}
+	}
+	if !sawUnreachable {
+		t.Errorf("patch tolerations = %+v, want the missing unreachable toleration added", tolerations)
+	}
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// TolerationPolicy declares one toleration that should be injected into pods
+// matching a Policy's Selector. It mirrors corev1.Toleration but is expressed
+// in a config file instead of hard-coded Go constants.
+type TolerationPolicy struct {
+	Key               string                    `json:"key"`
+	Operator          corev1.TolerationOperator `json:"operator,omitempty"`
+	Effect            corev1.TaintEffect        `json:"effect,omitempty"`
+	TolerationSeconds *int64                    `json:"tolerationSeconds,omitempty"`
+}
+
+func (tp TolerationPolicy) toCoreToleration() corev1.Toleration {
+	operator := tp.Operator
+	if operator == "" {
+		operator = corev1.TolerationOpExists
+	}
+
+	return corev1.Toleration{
+		Key:               tp.Key,
+		Operator:          operator,
+		Effect:            tp.Effect,
+		TolerationSeconds: tp.TolerationSeconds,
+	}
+}
+
+// PodSelector gates which pods a Policy applies to.
+type PodSelector struct {
+	// MatchLabels is matched against the pod's own labels.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+	// Namespaces restricts the policy to the given namespaces; empty matches every namespace.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// Matches reports whether pod falls within s.
+func (s PodSelector) Matches(pod corev1.Pod) bool {
+	if len(s.Namespaces) > 0 {
+		namespaceMatches := false
+		for _, ns := range s.Namespaces {
+			if ns == pod.Namespace {
+				namespaceMatches = true
+				break
+			}
+		}
+		if !namespaceMatches {
+			return false
+		}
+	}
+
+	for key, value := range s.MatchLabels {
+		if pod.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Policy is the declarative toleration-injection config: which pods Selector
+// matches, and the set of Tolerations to ensure on them.
+type Policy struct {
+	Selector    PodSelector        `json:"selector"`
+	Tolerations []TolerationPolicy `json:"tolerations"`
+}
+
+var (
+	policyMu      sync.RWMutex
+	currentPolicy = defaultPolicy()
+)
+
+// defaultPolicy reproduces the webhook's historical, hard-coded behaviour: inject
+// the not-ready/unreachable tolerations into virt-launcher pods. It is used
+// whenever no --config file is given, so the webhook keeps working out of the box.
+func defaultPolicy() Policy {
+	notReadySeconds := defaultTolerationSeconds
+	unreachableSeconds := defaultTolerationSeconds
+
+	return Policy{
+		Selector: PodSelector{
+			MatchLabels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+		Tolerations: []TolerationPolicy{
+			{
+				Key:               notReadyTolerationsKey,
+				Operator:          corev1.TolerationOpExists,
+				Effect:            corev1.TaintEffectNoExecute,
+				TolerationSeconds: &notReadySeconds,
+			},
+			{
+				Key:               unreachableTolerationsKey,
+				Operator:          corev1.TolerationOpExists,
+				Effect:            corev1.TaintEffectNoExecute,
+				TolerationSeconds: &unreachableSeconds,
+			},
+		},
+	}
+}
+
+// getPolicy returns the policy currently in effect.
+func getPolicy() Policy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return currentPolicy
+}
+
+// LoadPolicy loads the toleration policy from path and, for as long as the
+// process runs, hot-reloads it whenever the file changes on disk. An empty
+// path leaves the built-in defaultPolicy in effect.
+func LoadPolicy(path string) error {
+	if path == "" {
+		klog.Info("No --config given, using the built-in virt-launcher toleration policy")
+		return nil
+	}
+
+	if err := reloadPolicy(path); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create config watcher: %w", err)
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("could not watch %s: %w", path, err)
+	}
+
+	go watchPolicy(watcher, path)
+
+	return nil
+}
+
+func watchPolicy(watcher *fsnotify.Watcher, path string) {
+	for event := range watcher.Events {
+		if filepath.Clean(event.Name) != filepath.Clean(path) {
+			continue
+		}
+
+		if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			continue
+		}
+
+		if err := reloadPolicy(path); err != nil {
+			klog.Errorf("Could not reload policy %s: %s", path, err)
+			continue
+		}
+
+		klog.Infof("Reloaded toleration policy from %s", path)
+	}
+}
+
+func reloadPolicy(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	var loaded Policy
+	if err := yaml.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	policyMu.Lock()
+	currentPolicy = loaded
+	policyMu.Unlock()
+
+	return nil
+}
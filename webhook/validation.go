@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// maxTolerationSeconds bounds how long a user-supplied toleration for a key
+// the policy sets tolerationSeconds for (not-ready/unreachable under the
+// default policy) may run. A virt-launcher pod that tolerates a dead node for
+// longer than this outlives KubeVirt's own failover detection, so it is
+// rejected; HandleMutate leaves a toleration the pod already carries as it is.
+const maxTolerationSeconds int64 = 3600
+
+// HandleValidate services the /validate endpoint, rejecting virt-launcher pod
+// manifests whose tolerations conflict with the webhook's configured
+// defaults, which HandleMutate would otherwise leave in place unchanged.
+func HandleValidate(w http.ResponseWriter, r *http.Request) {
+	serveAdmission(w, r, validate)
+}
+
+func validate(pod corev1.Pod) admissionResult {
+	if !namespaceAllowed(pod) || !isVirtLauncherPod(pod) {
+		return admissionResult{allowed: true}
+	}
+
+	if reason := conflictingTolerationReason(pod); reason != "" {
+		klog.Infof("Rejecting pod %s/%s: %s", pod.Namespace, pod.Name, reason)
+		return admissionResult{message: reason}
+	}
+
+	return admissionResult{allowed: true}
+}
+
+// conflictingTolerationReason returns a human-readable rejection reason when
+// pod carries a toleration that conflicts with the currently loaded policy
+// (see policy.go), or "" if the pod's tolerations are fine. The set of keys
+// checked and the minimum tolerationSeconds for each come from the policy
+// itself, so a --config that injects a custom taint or a different threshold
+// is enforced here exactly as HandleMutate would apply it.
+func conflictingTolerationReason(pod corev1.Pod) string {
+	minSeconds := policyMinTolerationSeconds()
+
+	for _, toleration := range pod.Spec.Tolerations {
+		configuredSeconds, ok := minSeconds[toleration.Key]
+		if !ok {
+			continue
+		}
+
+		if reason := checkTolerationSeconds(toleration, configuredSeconds); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+// policyMinTolerationSeconds maps each toleration key the current policy
+// manages to the minimum tolerationSeconds it configures for that key.
+// Keys the policy declares without a tolerationSeconds are omitted, since
+// there is then no configured minimum to enforce.
+func policyMinTolerationSeconds() map[string]int64 {
+	policyTolerations := getPolicy().Tolerations
+	minSeconds := make(map[string]int64, len(policyTolerations))
+
+	for _, tp := range policyTolerations {
+		if tp.TolerationSeconds != nil {
+			minSeconds[tp.Key] = *tp.TolerationSeconds
+		}
+	}
+
+	return minSeconds
+}
+
+func checkTolerationSeconds(toleration corev1.Toleration, configuredSeconds int64) string {
+	if toleration.TolerationSeconds == nil {
+		return fmt.Sprintf("toleration %q must set tolerationSeconds", toleration.Key)
+	}
+
+	if *toleration.TolerationSeconds < configuredSeconds {
+		return fmt.Sprintf("toleration %q sets tolerationSeconds=%d, lower than the configured %d",
+			toleration.Key, *toleration.TolerationSeconds, configuredSeconds)
+	}
+
+	if *toleration.TolerationSeconds > maxTolerationSeconds {
+		return fmt.Sprintf("toleration %q sets tolerationSeconds=%d, exceeding the maximum %d allowed for KubeVirt failover",
+			toleration.Key, *toleration.TolerationSeconds, maxTolerationSeconds)
+	}
+
+	return ""
+}
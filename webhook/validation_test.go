@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateRejectsTolerationSecondsBelowDefault(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(5)},
+			},
+		},
+	}
+
+	result := validate(pod)
+	if result.allowed || result.message == "" {
+		t.Errorf("validate() of a pod with tolerationSeconds=5 = %+v, want rejected", result)
+	}
+}
+
+func TestValidateRejectsTolerationSecondsAboveMax(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: unreachableTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(maxTolerationSeconds + 1)},
+			},
+		},
+	}
+
+	result := validate(pod)
+	if result.allowed || result.message == "" {
+		t.Errorf("validate() of a pod with tolerationSeconds above the max = %+v, want rejected", result)
+	}
+}
+
+func TestValidateAllowsTolerationWithinRange(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(defaultTolerationSeconds)},
+			},
+		},
+	}
+
+	result := validate(pod)
+	if !result.allowed {
+		t.Errorf("validate() of a pod with an in-range toleration = %+v, want allowed", result)
+	}
+}
+
+func TestValidateHonorsPolicyThresholdAndKeys(t *testing.T) {
+	const customKey = "example.com/custom-taint"
+	const customSeconds int64 = 600
+
+	policyMu.Lock()
+	previous := currentPolicy
+	currentPolicy = Policy{
+		Selector: PodSelector{MatchLabels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue}},
+		Tolerations: []TolerationPolicy{
+			{Key: customKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(customSeconds)},
+		},
+	}
+	policyMu.Unlock()
+	defer func() {
+		policyMu.Lock()
+		currentPolicy = previous
+		policyMu.Unlock()
+	}()
+
+	podBelow := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue}},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: customKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(customSeconds - 1)},
+			},
+		},
+	}
+	if result := validate(podBelow); result.allowed {
+		t.Errorf("validate() of a pod below the policy's %s threshold = %+v, want rejected", customKey, result)
+	}
+
+	podNotReady := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{virtLauncherLabelKey: virtLauncherLabelValue}},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(5)},
+			},
+		},
+	}
+	if result := validate(podNotReady); !result.allowed {
+		t.Errorf("validate() of a pod tolerating a key outside the loaded policy = %+v, want allowed", result)
+	}
+}
+
+func TestValidateSkipsDisallowedNamespace(t *testing.T) {
+	AllowedNamespaces = []string{"kubevirt-system"}
+	defer func() { AllowedNamespaces = nil }()
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Labels:    map[string]string{virtLauncherLabelKey: virtLauncherLabelValue},
+		},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: notReadyTolerationsKey, Operator: corev1.TolerationOpExists, TolerationSeconds: secondsPtr(5)},
+			},
+		},
+	}
+
+	result := validate(pod)
+	if !result.allowed {
+		t.Errorf("validate() outside the namespace allow-list = %+v, want allowed", result)
+	}
+}